@@ -0,0 +1,217 @@
+package main
+
+import "C"
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/homedir"
+
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+)
+
+// clientEntry bundles a REST client together with the namespace its
+// caller asked to default to, so later helpers can resolve "current
+// namespace" without threading it through every call.
+type clientEntry struct {
+	rest      *rest.RESTClient
+	config    *rest.Config
+	namespace string
+}
+
+var clients map[uint64]*clientEntry
+var nextClientId uint64
+
+// There is no per-client goroutine pool or request queue: every
+// exported call runs on the caller's thread. Every exported function
+// that can fail reports the failure as a JSON requestError (code,
+// reason, message) through errPtr/errLen out-params; StopWatch and
+// FreeBytes have no error out-params.
+//
+// mtx only guards the clients registry itself (inserts/lookups), not
+// the REST calls made against a *rest.RESTClient once it's been
+// looked up. Request, DynamicRequest, Helm* and the watch subsystem
+// all release mtx before doing any network I/O, so calls against
+// different clients — and concurrent calls against the same client,
+// since *rest.RESTClient is itself safe for concurrent use — already
+// run in parallel instead of queueing behind one global lock.
+var mtx sync.Mutex
+
+// newRESTClient finishes off a *rest.Config the same way for every
+// entry point: unversioned, since this plugin deals in raw REST calls
+// rather than a generated typed client.
+func newRESTClient(config *rest.Config) (*rest.RESTClient, error) {
+	config.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	return rest.UnversionedRESTClientFor(config)
+}
+
+// register stores a freshly built client under a new id. Callers must
+// hold mtx.
+func register(restClient *rest.RESTClient, config *rest.Config, namespace string) uint64 {
+	if clients == nil {
+		clients = make(map[uint64]*clientEntry)
+	}
+	clientId := nextClientId
+	nextClientId += 1
+	clients[clientId] = &clientEntry{rest: restClient, config: config, namespace: namespace}
+	return clientId
+}
+
+// defaultKubeconfigPath mirrors client-go's own convention of
+// defaulting to $HOME/.kube/config when no path is given.
+func defaultKubeconfigPath() string {
+	if home := homedir.HomeDir(); home != "" {
+		return filepath.Join(home, ".kube", "config")
+	}
+	return ""
+}
+
+// inClusterNamespace reads the namespace a pod's service account is
+// bound to, the same file kubectl and client-go consult when running
+// in-cluster.
+func inClusterNamespace() string {
+	const namespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	if raw, err := ioutil.ReadFile(namespacePath); err == nil {
+		return strings.TrimSpace(string(raw))
+	}
+	return "default"
+}
+
+// Init resolves a client the way client-go recommends for code that
+// might run either inside or outside a cluster: it tries the in-pod
+// service account first (rest.InClusterConfig) and only falls back to
+// $HOME/.kube/config, using the kubeconfig's current-context, if
+// InClusterConfig itself fails (i.e. we're not running in a pod).
+// Callers that need to pick a specific path, context or namespace
+// should use InitFromConfig instead.
+//
+// Like every other exported function, failure is reported as a
+// structured requestError through outErr rather than a panic, so a
+// missing or malformed kubeconfig can't take down the Deno process.
+//
+//export Init
+func Init(outClientId *C.uint64_t, outErrPtr **C.char, outErrLen *C.int) C.int {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if config, err := rest.InClusterConfig(); err == nil {
+		// We are in a pod: a failure past this point is a real
+		// in-cluster config problem, not a reason to fall back to a
+		// kubeconfig that likely doesn't even exist in the container.
+		restClient, err := newRESTClient(config)
+		if err != nil {
+			writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+			return 0
+		}
+		*outClientId = C.uint64_t(register(restClient, config, inClusterNamespace()))
+		return 1
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", defaultKubeconfigPath())
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+	restClient, err := newRESTClient(config)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+
+	*outClientId = C.uint64_t(register(restClient, config, "default"))
+	return 1
+}
+
+// InitInCluster builds a client strictly from the in-pod service
+// account, returning 0 and writing to outErr if the process isn't
+// actually running inside a cluster.
+//
+//export InitInCluster
+func InitInCluster(outClientId *C.uint64_t, outErrPtr **C.char, outErrLen *C.int) C.int {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "NotInCluster", Message: err.Error()})
+		return 0
+	}
+	restClient, err := newRESTClient(config)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+
+	*outClientId = C.uint64_t(register(restClient, config, inClusterNamespace()))
+	return 1
+}
+
+// InitFromConfig builds a client from an explicit kubeconfig file,
+// letting the caller pick which context, namespace, server override
+// and bearer token to use instead of the implicit $HOME/.kube/config
+// + current-context that Init falls back to. An empty kubeconfigPath
+// defaults to $HOME/.kube/config, an empty contextName defaults to
+// the kubeconfig's current-context, an empty namespace defaults to
+// "default", and an empty serverOverride/bearerToken leave the
+// kubeconfig's own values in place.
+//
+// Context/server/token selection goes through
+// clientcmd.ConfigOverrides rather than BuildConfigFromFlags, whose
+// first argument is the API server URL (masterUrl), not a context
+// name — passing contextName there would both silently ignore context
+// selection and corrupt the server URL with a garbage value.
+//
+//export InitFromConfig
+func InitFromConfig(
+	kubeconfigPathPtr *C.char, kubeconfigPathLen C.int,
+	contextNamePtr *C.char, contextNameLen C.int,
+	namespacePtr *C.char, namespaceLen C.int,
+	serverOverridePtr *C.char, serverOverrideLen C.int,
+	bearerTokenPtr *C.char, bearerTokenLen C.int,
+	outClientId *C.uint64_t,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	kubeconfigPath := string(fromCBytes(kubeconfigPathPtr, kubeconfigPathLen))
+	if kubeconfigPath == "" {
+		kubeconfigPath = defaultKubeconfigPath()
+	}
+	contextName := string(fromCBytes(contextNamePtr, contextNameLen))
+	namespace := string(fromCBytes(namespacePtr, namespaceLen))
+	if namespace == "" {
+		namespace = "default"
+	}
+	serverOverride := string(fromCBytes(serverOverridePtr, serverOverrideLen))
+	bearerToken := string(fromCBytes(bearerTokenPtr, bearerTokenLen))
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: contextName}
+	if serverOverride != "" {
+		overrides.ClusterInfo.Server = serverOverride
+	}
+	if bearerToken != "" {
+		overrides.AuthInfo.Token = bearerToken
+	}
+
+	loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfigPath}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+	restClient, err := newRESTClient(config)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+
+	*outClientId = C.uint64_t(register(restClient, config, namespace))
+	return 1
+}
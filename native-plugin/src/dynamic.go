@@ -0,0 +1,274 @@
+package main
+
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	diskcached "k8s.io/client-go/discovery/cached/disk"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/util/homedir"
+)
+
+// resettableRESTMapper is the subset of
+// *restmapper.DeferredDiscoveryRESTMapper's API invalidate needs: it
+// caches GVK/GVR mappings in memory on top of a CachedDiscoveryInterface,
+// and Reset() clears that in-memory cache so the next lookup re-reads
+// discovery instead of serving stale mappings forever.
+type resettableRESTMapper interface {
+	meta.RESTMapper
+	Reset()
+}
+
+// dynamicEntry lazily builds and caches the dynamic client, discovery
+// client and RESTMapper for a clientEntry; running discovery against
+// every API group on the cluster is expensive enough that it
+// shouldn't happen on every DynamicRequest call.
+type dynamicEntry struct {
+	mu        sync.Mutex
+	client    dynamic.Interface
+	discovery discovery.CachedDiscoveryInterface
+	mapper    resettableRESTMapper
+}
+
+var dynamicEntries map[uint64]*dynamicEntry
+var dynamicMtx sync.Mutex
+
+// getDynamicEntry returns the (lazily created) dynamicEntry for a
+// registered client, along with the *rest.Config needed to build its
+// dynamic client and discovery client on first use.
+func getDynamicEntry(clientId uint64) (*dynamicEntry, *rest.Config, error) {
+	mtx.Lock()
+	entry := clients[clientId]
+	mtx.Unlock()
+	if entry == nil {
+		return nil, nil, fmt.Errorf("unknown client id")
+	}
+
+	dynamicMtx.Lock()
+	defer dynamicMtx.Unlock()
+	if dynamicEntries == nil {
+		dynamicEntries = make(map[uint64]*dynamicEntry)
+	}
+	d := dynamicEntries[clientId]
+	if d == nil {
+		d = &dynamicEntry{}
+		dynamicEntries[clientId] = d
+	}
+	return d, entry.config, nil
+}
+
+// resolve returns the dynamic client and RESTMapper for this entry,
+// building and caching them on first use.
+func (d *dynamicEntry) resolve(config *rest.Config) (dynamic.Interface, meta.RESTMapper, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.client != nil && d.mapper != nil {
+		return d.client, d.mapper, nil
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	discoveryClient, err := newCachedDiscoveryClient(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d.client = dynamicClient
+	d.discovery = discoveryClient
+	d.mapper = restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+	return d.client, d.mapper, nil
+}
+
+// invalidate forces the next resolve to re-run discovery from a fresh
+// API server read: it purges the on-disk discovery cache via
+// CachedDiscoveryInterface.Invalidate() and resets the in-memory
+// RESTMapper. Just dropping d.mapper and rebuilding it over the same
+// cached discovery client wouldn't work here, since
+// newCachedDiscoveryClient's on-disk cache has its own 10-minute TTL
+// and would keep serving the same stale group/resource list; this is
+// used after a 404 or a no-match mapping error that might mean a CRD
+// was registered after that cache was populated.
+func (d *dynamicEntry) invalidate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.discovery != nil {
+		d.discovery.Invalidate()
+	}
+	if d.mapper != nil {
+		d.mapper.Reset()
+	}
+}
+
+// newCachedDiscoveryClient mirrors kubectl's own discovery cache
+// layout under $HOME/.kube/cache so repeated DynamicRequest calls
+// (and separate Deno processes) share warm discovery data on disk.
+func newCachedDiscoveryClient(config *rest.Config) (discovery.CachedDiscoveryInterface, error) {
+	// kubectl keeps one discovery cache directory per API server host.
+	schemelessHost := strings.Replace(strings.Replace(config.Host, "https://", "", 1), "http://", "", 1)
+	safeHost := regexp.MustCompile(`[^(\w/.)]`).ReplaceAllString(schemelessHost, "_")
+	cacheDir := filepath.Join(homedir.HomeDir(), ".kube", "cache", "discovery", safeHost)
+	httpCacheDir := filepath.Join(homedir.HomeDir(), ".kube", "cache", "http")
+	return diskcached.NewCachedDiscoveryClientForConfig(config, cacheDir, httpCacheDir, 10*time.Minute)
+}
+
+// parseGVR reads the "group/version/resource" form DynamicRequest
+// expects, using an empty group for core resources (e.g.
+// "/v1/pods").
+func parseGVR(raw string) (schema.GroupVersionResource, error) {
+	parts := strings.SplitN(raw, "/", 3)
+	if len(parts) != 3 {
+		return schema.GroupVersionResource{}, fmt.Errorf("expected gvr as \"group/version/resource\" (empty group for core resources), got %q", raw)
+	}
+	return schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}, nil
+}
+
+// resourceInterfaceFor resolves a GVR to the right dynamic
+// ResourceInterface, consulting the RESTMapper to decide whether the
+// resource is namespaced the same way kubectl does for arbitrary
+// (including CRD) resources.
+func resourceInterfaceFor(client dynamic.Interface, mapper meta.RESTMapper, gvr schema.GroupVersionResource, namespace string) (dynamic.ResourceInterface, error) {
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return client.Resource(mapping.Resource), nil
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	return client.Resource(mapping.Resource).Namespace(namespace), nil
+}
+
+// runDynamicRequest performs a single verb against a resolved
+// ResourceInterface and returns the JSON-encoded result.
+func runDynamicRequest(d *dynamicEntry, config *rest.Config, gvr schema.GroupVersionResource, namespace, name, verb string, body []byte) ([]byte, error) {
+	client, mapper, err := d.resolve(config)
+	if err != nil {
+		return nil, err
+	}
+	resourceInterface, err := resourceInterfaceFor(client, mapper, gvr, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.TODO()
+	switch verb {
+	case "GET":
+		obj, err := resourceInterface.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(obj)
+
+	case "LIST":
+		list, err := resourceInterface.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(list)
+
+	case "CREATE", "UPDATE":
+		object := &unstructured.Unstructured{}
+		if err := json.Unmarshal(body, object); err != nil {
+			return nil, err
+		}
+		var obj *unstructured.Unstructured
+		if verb == "CREATE" {
+			obj, err = resourceInterface.Create(ctx, object, metav1.CreateOptions{})
+		} else {
+			obj, err = resourceInterface.Update(ctx, object, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(obj)
+
+	case "DELETE":
+		if err := resourceInterface.Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+			return nil, err
+		}
+		return []byte(`{}`), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported verb %q", verb)
+	}
+}
+
+// DynamicRequest operates on an arbitrary GroupVersionResource —
+// including CRDs — without requiring the type to be pre-registered in
+// a scheme, backed by k8s.io/client-go/dynamic and a disk-cached
+// RESTMapper built from discovery. gvr is given as
+// "group/version/resource" (empty group for core resources); verb is
+// one of GET/LIST/CREATE/UPDATE/DELETE; body carries the JSON payload
+// for CREATE/UPDATE. A 404, or a no-match error from the RESTMapper,
+// triggers one retry against a freshly discovered RESTMapper, in case the resource is a CRD registered
+// after the on-disk cache was last populated.
+//
+//export DynamicRequest
+func DynamicRequest(
+	clientId C.uint64_t,
+	gvrPtr *C.char, gvrLen C.int,
+	namespacePtr *C.char, namespaceLen C.int,
+	namePtr *C.char, nameLen C.int,
+	verbPtr *C.char, verbLen C.int,
+	bodyPtr *C.char, bodyLen C.int,
+	outBodyPtr **C.char, outBodyLen *C.int,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	d, config, err := getDynamicEntry(uint64(clientId))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ClientNotFound", Message: err.Error()})
+		return 0
+	}
+
+	gvr, err := parseGVR(string(fromCBytes(gvrPtr, gvrLen)))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "BadRequest", Message: err.Error()})
+		return 0
+	}
+	namespace := string(fromCBytes(namespacePtr, namespaceLen))
+	name := string(fromCBytes(namePtr, nameLen))
+	verb := strings.ToUpper(string(fromCBytes(verbPtr, verbLen)))
+	body := fromCBytes(bodyPtr, bodyLen)
+
+	result, err := runDynamicRequest(d, config, gvr, namespace, name, verb, body)
+	if err != nil && (errors.IsNotFound(err) || meta.IsNoMatchError(err)) {
+		d.invalidate()
+		result, err = runDynamicRequest(d, config, gvr, namespace, name, verb, body)
+	}
+	if err != nil {
+		writeError(outErrPtr, outErrLen, statusErrorToRequestError(err))
+		return 0
+	}
+
+	ptr, length := toCBytes(result)
+	*outBodyPtr = ptr
+	*outBodyLen = length
+	return 1
+}
@@ -0,0 +1,45 @@
+package main
+
+import "C"
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// requestError is the structured shape returned to Deno in place of a
+// panic whenever a REST call fails, so callers can branch on Reason
+// (NotFound, Conflict, Forbidden, ...) the same way client-go does.
+type requestError struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// statusErrorToRequestError unwraps a client-go *errors.StatusError
+// into the wire struct sent back across the FFI boundary, falling
+// back to a generic reason for anything else (timeouts, transport
+// errors, ...).
+func statusErrorToRequestError(err error) *requestError {
+	if statusErr, ok := err.(*errors.StatusError); ok {
+		return &requestError{
+			Code:    int(statusErr.ErrStatus.Code),
+			Reason:  string(statusErr.ErrStatus.Reason),
+			Message: statusErr.ErrStatus.Message,
+		}
+	}
+	return &requestError{Reason: "Unknown", Message: err.Error()}
+}
+
+// writeError JSON-encodes a requestError onto the C heap and stores
+// the pointer/length pair through the caller's out-params.
+func writeError(outPtr **C.char, outLen *C.int, reqErr *requestError) {
+	encoded, err := json.Marshal(reqErr)
+	if err != nil {
+		encoded = []byte(`{"reason":"Unknown","message":"failed to encode error"}`)
+	}
+	ptr, length := toCBytes(encoded)
+	*outPtr = ptr
+	*outLen = length
+}
@@ -0,0 +1,65 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"strings"
+	"unsafe"
+)
+
+// FreeBytes releases a buffer previously handed back to the caller
+// through one of the exported functions (e.g. Request's outBodyPtr or
+// outErrPtr). Deno must call this once it has copied the bytes out, or
+// the C heap leaks for the lifetime of the process.
+//export FreeBytes
+func FreeBytes(ptr *C.char) {
+	C.free(unsafe.Pointer(ptr))
+}
+
+// toCBytes copies a Go byte slice onto the C heap so it survives past
+// the end of the current call, returning the pointer/length pair the
+// caller should eventually pass to FreeBytes.
+func toCBytes(b []byte) (*C.char, C.int) {
+	if len(b) == 0 {
+		return nil, 0
+	}
+	return (*C.char)(C.CBytes(b)), C.int(len(b))
+}
+
+// fromCBytes copies a pointer/length pair handed in across the FFI
+// boundary into a fresh Go byte slice. Every argument to Request
+// crosses the boundary this way instead of as an opaque uint64, so
+// arbitrary method names, paths, bodies and headers can be passed
+// without relying on NUL-terminated C strings.
+func fromCBytes(ptr *C.char, length C.int) []byte {
+	if ptr == nil || length == 0 {
+		return nil
+	}
+	return C.GoBytes(unsafe.Pointer(ptr), length)
+}
+
+// parseHeaders turns a plain "Key: Value\n"-per-line header blob, the
+// wire format Deno callers send headers in, into the map shape a
+// rest.Request expects.
+func parseHeaders(raw []byte) map[string][]string {
+	headers := map[string][]string{}
+	if len(raw) == 0 {
+		return headers
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		headers[key] = append(headers[key], value)
+	}
+	return headers
+}
@@ -0,0 +1,393 @@
+package main
+
+import "C"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// configRESTClientGetter adapts the *rest.Config a clientEntry already
+// holds (built by Init/InitInCluster/InitFromConfig) into the
+// genericclioptions.RESTClientGetter shape Helm's action.Configuration
+// wants, so Helm drives the same cluster connection Request and
+// DynamicRequest do instead of reading its own kubeconfig.
+type configRESTClientGetter struct {
+	config    *rest.Config
+	namespace string
+}
+
+func (g *configRESTClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return g.config, nil
+}
+
+func (g *configRESTClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	return newCachedDiscoveryClient(g.config)
+}
+
+func (g *configRESTClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	discoveryClient, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	return restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient), nil
+}
+
+// ToRawKubeConfigLoader returns a clientcmd.ClientConfig backed by the
+// same *rest.Config and namespace this getter already holds, rather
+// than one built from ambient $HOME/.kube/config or $KUBECONFIG. None
+// of install/upgrade/uninstall/status/list are known to call it
+// today, but if a future Helm code path does, it must see the same
+// cluster connection Request/DynamicRequest use instead of silently
+// targeting whatever happens to be on disk — which would matter a lot
+// for an in-cluster or InitFromConfig-built client with no kubeconfig
+// file at all.
+func (g *configRESTClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return &restConfigClientConfig{config: g.config, namespace: g.namespace}
+}
+
+// restConfigClientConfig implements clientcmd.ClientConfig directly
+// over an in-memory *rest.Config. Its RawConfig() cannot be satisfied
+// honestly — a *rest.Config has no kubeconfig-file representation to
+// hand back — so it errors rather than silently reading one from
+// disk.
+type restConfigClientConfig struct {
+	config    *rest.Config
+	namespace string
+}
+
+func (c *restConfigClientConfig) RawConfig() (clientcmdapi.Config, error) {
+	return clientcmdapi.Config{}, fmt.Errorf("raw kubeconfig access is unsupported for a client built from an in-memory *rest.Config")
+}
+
+func (c *restConfigClientConfig) ClientConfig() (*rest.Config, error) {
+	return c.config, nil
+}
+
+func (c *restConfigClientConfig) Namespace() (string, bool, error) {
+	return c.namespace, false, nil
+}
+
+func (c *restConfigClientConfig) ConfigAccess() clientcmd.ConfigAccess {
+	return clientcmd.NewDefaultClientConfigLoadingRules()
+}
+
+// configFor looks up the *rest.Config and default namespace stored
+// for a client id by Init/InitInCluster/InitFromConfig.
+func configFor(clientId uint64) (*rest.Config, string, error) {
+	mtx.Lock()
+	entry := clients[clientId]
+	mtx.Unlock()
+	if entry == nil {
+		return nil, "", fmt.Errorf("unknown client id")
+	}
+	return entry.config, entry.namespace, nil
+}
+
+// newActionConfig builds a Helm action.Configuration backed by the
+// given cluster connection, storing release metadata with the
+// standard "secret" driver the same way the helm CLI does.
+func newActionConfig(config *rest.Config, namespace string) (*action.Configuration, error) {
+	getter := &configRESTClientGetter{config: config, namespace: namespace}
+	actionConfig := new(action.Configuration)
+	if err := actionConfig.Init(getter, namespace, "secret", log.Printf); err != nil {
+		return nil, err
+	}
+	return actionConfig, nil
+}
+
+// parseHelmValues decodes the valuesJSON argument into the
+// map[string]interface{} shape Helm's chart rendering expects.
+func parseHelmValues(raw []byte) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if len(raw) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// helmReleaseWire is the shape of a single release sent back to Deno,
+// including its rendered manifest so callers can inspect exactly what
+// was (or would be) applied to the cluster.
+type helmReleaseWire struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"revision"`
+	Status    string `json:"status"`
+	Manifest  string `json:"manifest"`
+}
+
+func encodeHelmRelease(rel *release.Release) ([]byte, error) {
+	return json.Marshal(releaseToWire(rel))
+}
+
+func releaseToWire(rel *release.Release) helmReleaseWire {
+	wire := helmReleaseWire{Name: rel.Name, Namespace: rel.Namespace, Revision: rel.Version, Manifest: rel.Manifest}
+	if rel.Info != nil {
+		wire.Status = rel.Info.Status.String()
+	}
+	return wire
+}
+
+// HelmInstall renders chartBytes (a loaded .tgz archive) with
+// valuesJSON and installs it as releaseName, the same two steps
+// `helm install` performs via action.NewInstall.
+//
+//export HelmInstall
+func HelmInstall(
+	clientId C.uint64_t,
+	releaseNamePtr *C.char, releaseNameLen C.int,
+	namespacePtr *C.char, namespaceLen C.int,
+	chartPtr *C.char, chartLen C.int,
+	valuesPtr *C.char, valuesLen C.int,
+	outBodyPtr **C.char, outBodyLen *C.int,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	config, defaultNamespace, err := configFor(uint64(clientId))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ClientNotFound", Message: err.Error()})
+		return 0
+	}
+
+	releaseName := string(fromCBytes(releaseNamePtr, releaseNameLen))
+	namespace := string(fromCBytes(namespacePtr, namespaceLen))
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	chrt, err := loader.LoadArchive(bytes.NewReader(fromCBytes(chartPtr, chartLen)))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "BadRequest", Message: err.Error()})
+		return 0
+	}
+	values, err := parseHelmValues(fromCBytes(valuesPtr, valuesLen))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "BadRequest", Message: err.Error()})
+		return 0
+	}
+
+	actionConfig, err := newActionConfig(config, namespace)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+
+	install := action.NewInstall(actionConfig)
+	install.ReleaseName = releaseName
+	install.Namespace = namespace
+
+	rel, err := install.Run(chrt, values)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "HelmError", Message: err.Error()})
+		return 0
+	}
+
+	return writeHelmRelease(rel, outBodyPtr, outBodyLen, outErrPtr, outErrLen)
+}
+
+// HelmUpgrade re-renders chartBytes with valuesJSON and upgrades the
+// existing releaseName in place via action.NewUpgrade.
+//
+//export HelmUpgrade
+func HelmUpgrade(
+	clientId C.uint64_t,
+	releaseNamePtr *C.char, releaseNameLen C.int,
+	namespacePtr *C.char, namespaceLen C.int,
+	chartPtr *C.char, chartLen C.int,
+	valuesPtr *C.char, valuesLen C.int,
+	outBodyPtr **C.char, outBodyLen *C.int,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	config, defaultNamespace, err := configFor(uint64(clientId))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ClientNotFound", Message: err.Error()})
+		return 0
+	}
+
+	releaseName := string(fromCBytes(releaseNamePtr, releaseNameLen))
+	namespace := string(fromCBytes(namespacePtr, namespaceLen))
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	chrt, err := loader.LoadArchive(bytes.NewReader(fromCBytes(chartPtr, chartLen)))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "BadRequest", Message: err.Error()})
+		return 0
+	}
+	values, err := parseHelmValues(fromCBytes(valuesPtr, valuesLen))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "BadRequest", Message: err.Error()})
+		return 0
+	}
+
+	actionConfig, err := newActionConfig(config, namespace)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+
+	upgrade := action.NewUpgrade(actionConfig)
+	upgrade.Namespace = namespace
+
+	rel, err := upgrade.Run(releaseName, chrt, values)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "HelmError", Message: err.Error()})
+		return 0
+	}
+
+	return writeHelmRelease(rel, outBodyPtr, outBodyLen, outErrPtr, outErrLen)
+}
+
+// HelmUninstall removes releaseName via action.NewUninstall.
+//
+//export HelmUninstall
+func HelmUninstall(
+	clientId C.uint64_t,
+	releaseNamePtr *C.char, releaseNameLen C.int,
+	namespacePtr *C.char, namespaceLen C.int,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	config, defaultNamespace, err := configFor(uint64(clientId))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ClientNotFound", Message: err.Error()})
+		return 0
+	}
+
+	releaseName := string(fromCBytes(releaseNamePtr, releaseNameLen))
+	namespace := string(fromCBytes(namespacePtr, namespaceLen))
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	actionConfig, err := newActionConfig(config, namespace)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+
+	if _, err := action.NewUninstall(actionConfig).Run(releaseName); err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "HelmError", Message: err.Error()})
+		return 0
+	}
+	return 1
+}
+
+// HelmStatus reports the current state of releaseName via
+// action.NewStatus. It reads the stored release record without
+// re-rendering the chart or changing anything in the cluster.
+//
+//export HelmStatus
+func HelmStatus(
+	clientId C.uint64_t,
+	releaseNamePtr *C.char, releaseNameLen C.int,
+	namespacePtr *C.char, namespaceLen C.int,
+	outBodyPtr **C.char, outBodyLen *C.int,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	config, defaultNamespace, err := configFor(uint64(clientId))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ClientNotFound", Message: err.Error()})
+		return 0
+	}
+
+	releaseName := string(fromCBytes(releaseNamePtr, releaseNameLen))
+	namespace := string(fromCBytes(namespacePtr, namespaceLen))
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	actionConfig, err := newActionConfig(config, namespace)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+
+	rel, err := action.NewStatus(actionConfig).Run(releaseName)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "HelmError", Message: err.Error()})
+		return 0
+	}
+
+	return writeHelmRelease(rel, outBodyPtr, outBodyLen, outErrPtr, outErrLen)
+}
+
+// HelmList lists the releases in namespace via action.NewList with its
+// default filter: only the latest revision of each release, and only
+// releases whose status is deployed or failed.
+//
+//export HelmList
+func HelmList(
+	clientId C.uint64_t,
+	namespacePtr *C.char, namespaceLen C.int,
+	outBodyPtr **C.char, outBodyLen *C.int,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	config, defaultNamespace, err := configFor(uint64(clientId))
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ClientNotFound", Message: err.Error()})
+		return 0
+	}
+
+	namespace := string(fromCBytes(namespacePtr, namespaceLen))
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	actionConfig, err := newActionConfig(config, namespace)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ConfigError", Message: err.Error()})
+		return 0
+	}
+
+	releases, err := action.NewList(actionConfig).Run()
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "HelmError", Message: err.Error()})
+		return 0
+	}
+
+	wire := make([]helmReleaseWire, len(releases))
+	for i, rel := range releases {
+		wire[i] = releaseToWire(rel)
+	}
+	encoded, err := json.Marshal(wire)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "Unknown", Message: err.Error()})
+		return 0
+	}
+
+	ptr, length := toCBytes(encoded)
+	*outBodyPtr = ptr
+	*outBodyLen = length
+	return 1
+}
+
+// writeHelmRelease JSON-encodes a single release and stores it
+// through the caller's out-params, converting an encoding failure
+// into the same structured error shape as any other request.
+func writeHelmRelease(rel *release.Release, outBodyPtr **C.char, outBodyLen *C.int, outErrPtr **C.char, outErrLen *C.int) C.int {
+	encoded, err := encodeHelmRelease(rel)
+	if err != nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "Unknown", Message: err.Error()})
+		return 0
+	}
+	ptr, length := toCBytes(encoded)
+	*outBodyPtr = ptr
+	*outBodyLen = length
+	return 1
+}
@@ -0,0 +1,83 @@
+package main
+
+import "C"
+
+import (
+	"context"
+	"time"
+)
+
+// Request issues a single arbitrary REST call against the client
+// identified by clientId: method/path/body/headers all cross the FFI
+// boundary as length-prefixed byte buffers rather than the single
+// opaque uint64 the old Submit function took. It mirrors the bottom
+// layer of client-go's four-client-object model (RESTClient) so that
+// typed helpers can eventually be layered on top in Deno instead of in
+// this plugin.
+//
+// On success it returns 1, writes the HTTP status code to *outStatus
+// and the response body to *outBodyPtr/*outBodyLen. On failure it
+// returns 0 and writes a JSON-encoded requestError to
+// *outErrPtr/*outErrLen instead of panicking. Any non-nil outBodyPtr
+// or outErrPtr must be released by the caller via FreeBytes.
+//
+//export Request
+func Request(
+	clientId C.uint64_t,
+	methodPtr *C.char, methodLen C.int,
+	pathPtr *C.char, pathLen C.int,
+	bodyPtr *C.char, bodyLen C.int,
+	headersPtr *C.char, headersLen C.int,
+	timeoutMs C.int,
+	outStatus *C.int,
+	outBodyPtr **C.char, outBodyLen *C.int,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	mtx.Lock()
+	entry := clients[uint64(clientId)]
+	mtx.Unlock()
+
+	if entry == nil {
+		writeError(outErrPtr, outErrLen, &requestError{
+			Reason:  "ClientNotFound",
+			Message: "unknown client id",
+		})
+		return 0
+	}
+	restClient := entry.rest
+
+	method := string(fromCBytes(methodPtr, methodLen))
+	path := string(fromCBytes(pathPtr, pathLen))
+	body := fromCBytes(bodyPtr, bodyLen)
+	headers := parseHeaders(fromCBytes(headersPtr, headersLen))
+
+	req := restClient.Verb(method).
+		RequestURI(path).
+		Timeout(time.Duration(timeoutMs) * time.Millisecond)
+
+	for key, values := range headers {
+		for _, value := range values {
+			req = req.SetHeader(key, value)
+		}
+	}
+	if len(body) > 0 {
+		req = req.Body(body)
+	}
+
+	result := req.Do(context.TODO())
+
+	var statusCode int
+	result.StatusCode(&statusCode)
+	*outStatus = C.int(statusCode)
+
+	raw, err := result.Raw()
+	if err != nil {
+		writeError(outErrPtr, outErrLen, statusErrorToRequestError(err))
+		return 0
+	}
+
+	ptr, length := toCBytes(raw)
+	*outBodyPtr = ptr
+	*outBodyLen = length
+	return 1
+}
@@ -0,0 +1,214 @@
+package main
+
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// maxBufferedWatchEvents caps how many undrained events a single
+// watch will hold in memory. A controller that stops calling
+// PollWatch (or a resource with high churn) must not be able to grow
+// this buffer without bound and OOM the Deno process.
+const maxBufferedWatchEvents = 1024
+
+// watchEntry holds the state for one live watch: a cancel func to
+// tear down the upstream request, and a bounded buffer of encoded
+// events waiting to be drained by PollWatch.
+type watchEntry struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	events [][]byte
+	done   bool
+}
+
+var watches map[uint64]*watchEntry
+var nextWatchId uint64
+var watchMtx sync.Mutex
+
+// watchWireEvent is the wire shape of a single buffered event; Type
+// is one of ADDED/MODIFIED/DELETED/BOOKMARK/ERROR, matching
+// watch.EventType, and Object is the raw JSON of the resource.
+type watchWireEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// StartWatch opens a long-lived watch against resourcePath and begins
+// buffering its events (up to maxBufferedWatchEvents) in the
+// background; PollWatch drains them and StopWatch tears the watch
+// down. This is a single raw watch request exposed directly over the
+// FFI boundary, since controllers built on top of this plugin need to
+// react to changes rather than poll Request in a loop. Unlike
+// client-go's reflector it does no initial list and does not re-watch
+// after the stream ends.
+//
+//export StartWatch
+func StartWatch(
+	clientId C.uint64_t,
+	resourcePathPtr *C.char, resourcePathLen C.int,
+	resourceVersionPtr *C.char, resourceVersionLen C.int,
+	labelSelectorPtr *C.char, labelSelectorLen C.int,
+	outWatchId *C.uint64_t,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	mtx.Lock()
+	entry := clients[uint64(clientId)]
+	mtx.Unlock()
+
+	if entry == nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "ClientNotFound", Message: "unknown client id"})
+		return 0
+	}
+
+	resourcePath := string(fromCBytes(resourcePathPtr, resourcePathLen))
+	resourceVersion := string(fromCBytes(resourceVersionPtr, resourceVersionLen))
+	labelSelector := string(fromCBytes(labelSelectorPtr, labelSelectorLen))
+
+	req := entry.rest.Get().
+		RequestURI(resourcePath).
+		Param("watch", "1")
+	if resourceVersion != "" {
+		req = req.Param("resourceVersion", resourceVersion)
+	}
+	if labelSelector != "" {
+		req = req.Param("labelSelector", labelSelector)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher, err := req.Watch(ctx)
+	if err != nil {
+		cancel()
+		writeError(outErrPtr, outErrLen, statusErrorToRequestError(err))
+		return 0
+	}
+
+	watchMtx.Lock()
+	if watches == nil {
+		watches = make(map[uint64]*watchEntry)
+	}
+	watchId := nextWatchId
+	nextWatchId += 1
+	w := &watchEntry{cancel: cancel}
+	watches[watchId] = w
+	watchMtx.Unlock()
+
+	go pumpWatch(w, watcher)
+
+	*outWatchId = C.uint64_t(watchId)
+	return 1
+}
+
+// pumpWatch drains the upstream watch.Interface into w's buffer until
+// it closes, StopWatch cancels the context backing it, or the buffer
+// overflows because nothing is calling PollWatch. On overflow a
+// synthetic ERROR event is appended and the watch is stopped rather
+// than letting it grow forever; the caller is expected to treat that
+// the same as any other terminal watch error and, if it still wants
+// events, start a new watch from the last resourceVersion it saw.
+func pumpWatch(w *watchEntry, watcher watch.Interface) {
+	defer watcher.Stop()
+	for event := range watcher.ResultChan() {
+		encoded, err := encodeWatchEvent(event)
+		if err != nil {
+			continue
+		}
+
+		w.mu.Lock()
+		if len(w.events) >= maxBufferedWatchEvents {
+			w.events = append(w.events, overflowWatchEvent())
+			w.mu.Unlock()
+			break
+		}
+		w.events = append(w.events, encoded)
+		w.mu.Unlock()
+	}
+	w.mu.Lock()
+	w.done = true
+	w.mu.Unlock()
+}
+
+// overflowWatchEvent is appended in place of whichever event tipped
+// the buffer over maxBufferedWatchEvents, telling the Deno side why
+// the watch stopped producing further events.
+func overflowWatchEvent() []byte {
+	message := fmt.Sprintf("watch buffer exceeded %d undrained events; watch stopped", maxBufferedWatchEvents)
+	object, _ := json.Marshal(map[string]string{"reason": "BufferOverflow", "message": message})
+	encoded, _ := json.Marshal(watchWireEvent{Type: string(watch.Error), Object: object})
+	return encoded
+}
+
+func encodeWatchEvent(event watch.Event) ([]byte, error) {
+	raw, err := json.Marshal(event.Object)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(watchWireEvent{Type: string(event.Type), Object: raw})
+}
+
+// PollWatch drains whatever events have accumulated for watchId since
+// the last call as newline-delimited JSON in
+// outEventsPtr/outEventsLen. A return of 0 with no error means the
+// watch has ended (the upstream channel closed); the caller should
+// still call StopWatch to release it.
+//
+//export PollWatch
+func PollWatch(
+	watchId C.uint64_t,
+	outEventsPtr **C.char, outEventsLen *C.int,
+	outErrPtr **C.char, outErrLen *C.int,
+) C.int {
+	watchMtx.Lock()
+	w := watches[uint64(watchId)]
+	watchMtx.Unlock()
+
+	if w == nil {
+		writeError(outErrPtr, outErrLen, &requestError{Reason: "WatchNotFound", Message: "unknown watch id"})
+		return 0
+	}
+
+	w.mu.Lock()
+	events := w.events
+	w.events = nil
+	done := w.done
+	w.mu.Unlock()
+
+	if len(events) == 0 {
+		if done {
+			return 0
+		}
+		return 1
+	}
+
+	var buf bytes.Buffer
+	for _, event := range events {
+		buf.Write(event)
+		buf.WriteByte('\n')
+	}
+
+	ptr, length := toCBytes(buf.Bytes())
+	*outEventsPtr = ptr
+	*outEventsLen = length
+	return 1
+}
+
+// StopWatch cancels the watch's context, releases its upstream
+// connection and drops its buffered events.
+//
+//export StopWatch
+func StopWatch(watchId C.uint64_t) {
+	watchMtx.Lock()
+	w := watches[uint64(watchId)]
+	delete(watches, uint64(watchId))
+	watchMtx.Unlock()
+
+	if w != nil {
+		w.cancel()
+	}
+}